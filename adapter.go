@@ -3,6 +3,7 @@ package csp
 import (
 	"errors"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -11,6 +12,7 @@ var ErrWrongChecksum = errors.New("wrong checksum")
 var ErrWrite = errors.New("write failed")
 var ErrWriteLength = errors.New("write failed to send all bytes")
 var ErrTimeout = errors.New("timeout")
+var ErrIdleTimeout = errors.New("idle timeout: no frame received from peer")
 
 const BeaconInterval = 6 * time.Second
 
@@ -20,6 +22,7 @@ const (
 	stateIdle byte = iota
 	stateHeader
 	stateDirection
+	stateAlgo
 	stateLength
 	stateCommand
 	statePayload
@@ -34,27 +37,71 @@ type Adapter struct {
 
 	state   byte
 	message Message
+
+	// frame accumulates header..payload of the message currently being
+	// parsed, so the negotiated Checksum can verify it once the trailer
+	// has been read in full.
+	frame         []byte
+	frameChecksum Checksum
+	trailer       []byte
+
+	checksum Checksum
+
+	pingInterval time.Duration
+	idleTimeout  time.Duration
+	lastActivity time.Time
+	lastPingSent time.Time
+
+	subsMu sync.RWMutex
+	subs   []subscription
+
+	// sendMu serializes writes to wire. Send is called concurrently from
+	// more than one goroutine in ordinary use (e.g. a Mux's sendLoop
+	// draining channel traffic while handlePingMaybe replies to an inbound
+	// CmdPing), and io.Writer gives no guarantee that a single Write call
+	// is atomic with respect to another goroutine's concurrent Write.
+	sendMu sync.Mutex
+
+	id byte
+
+	beaconsMu   sync.RWMutex
+	beaconsSeen map[byte]time.Time
 }
 
-func NewAdapter(wire io.ReadWriter) *Adapter {
-	return &Adapter{
-		wire: wire,
+func NewAdapter(wire io.ReadWriter, opts ...Option) *Adapter {
+	a := &Adapter{
+		wire:     wire,
+		checksum: xorChecksum{},
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
+	return a
 }
 
-// Send a message.
+// Send a message, appending the trailer for the adapter's negotiated
+// Checksum algorithm.
 func (a *Adapter) Send(message *Message) error {
-	bytes := message.Bytes()
+	raw := message.Bytes() // $, C, dir, len, cmd, payload..., legacy XOR byte
+	frame := make([]byte, 0, len(raw)+a.checksum.Size())
+	frame = append(frame, raw[:3]...) // $ C dir
+	frame = append(frame, checksumID(a.checksum))
+	frame = append(frame, raw[3:len(raw)-1]...) // len cmd payload
+	frame = append(frame, a.checksum.Compute(frame)...)
+
 	logTs("SEND ")
-	for _, b := range bytes {
+	for _, b := range frame {
 		log(" %02X", b)
 	}
 	log("\n")
-	n, err := a.wire.Write(bytes)
+
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	n, err := a.wire.Write(frame)
 	if err != nil {
 		return ErrWrite
 	}
-	if n != len(bytes) {
+	if n != len(frame) {
 		return ErrWriteLength
 	}
 	return nil
@@ -64,8 +111,18 @@ func (a *Adapter) Send(message *Message) error {
 func (a *Adapter) Receive() (*Message, error) {
 	buf := make([]byte, 16)
 	for {
+		// Checked up front, not just on the no-data path below: a peer
+		// that keeps the wire busy with bytes that never resolve to a
+		// valid frame (line noise) must not be able to hide from the
+		// idle timeout by starving the err/n==0 branch forever.
+		if a.idleTimeout > 0 && time.Since(a.lastActivity) >= a.idleTimeout {
+			return nil, ErrIdleTimeout
+		}
 		n, err := a.wire.Read(buf)
 		if err != nil || n == 0 {
+			if keepalive, kerr := a.checkKeepalive(); keepalive {
+				return nil, kerr
+			}
 			return nil, ErrNoData
 		}
 		for i := 0; i < n; i++ {
@@ -92,6 +149,17 @@ func (a *Adapter) Receive() (*Message, error) {
 					continue
 				}
 				a.message.Direction = Direction(b)
+				a.frame = append([]byte{}, a.message.Header[0], a.message.Header[1], b)
+				a.state = stateAlgo
+			case stateAlgo:
+				logTs("ALGO %02X\n", b)
+				checksum := checksumByID(b)
+				if checksum == nil {
+					a.state = stateIdle
+					continue
+				}
+				a.frameChecksum = checksum
+				a.frame = append(a.frame, b)
 				a.state = stateLength
 			case stateLength:
 				logTs("LENGTH %02X\n", b)
@@ -101,31 +169,52 @@ func (a *Adapter) Receive() (*Message, error) {
 				}
 				a.message.Length = b
 				a.message.Payload = []byte{}
-				a.message.Checksum = b
+				a.frame = append(a.frame, b)
 				a.state = stateCommand
 			case stateCommand:
 				logTs("COMMAND %02X\n", b)
 				a.message.Command = Command(b)
-				a.message.Checksum ^= b
-				a.state = statePayload
+				a.frame = append(a.frame, b)
+				if a.message.Length == 0 {
+					a.trailer = make([]byte, 0, a.frameChecksum.Size())
+					a.state = stateChecksum
+				} else {
+					a.state = statePayload
+				}
 			case statePayload:
 				a.message.Payload = append(a.message.Payload, b)
-				a.message.Checksum ^= b
+				a.frame = append(a.frame, b)
 				if len(a.message.Payload) == int(a.message.Length) {
+					a.trailer = make([]byte, 0, a.frameChecksum.Size())
 					a.state = stateChecksum
 				}
 			case stateChecksum:
+				a.trailer = append(a.trailer, b)
+				if len(a.trailer) < a.frameChecksum.Size() {
+					continue
+				}
 				logTs("PAYLOAD ")
-				for _, bb := range a.message.Bytes() {
+				for _, bb := range a.frame {
 					log(" %02X", bb)
 				}
 				log("\n")
-				logTs("CHECKSUM expected %02X ?= %02X actual\n", a.message.Checksum, b)
+				logTs("CHECKSUM trailer % 02X\n", a.trailer)
 				result := a.message
+				ok := a.frameChecksum.Verify(a.frame, a.trailer)
 				a.message = Message{}
+				a.frame = nil
+				a.trailer = nil
 				a.state = stateIdle
-				if result.Checksum == b {
+				if ok {
+					// Only a successfully parsed and verified frame counts
+					// as activity; line noise that never resolves to a
+					// valid frame must not mask a dead peer from the
+					// keepalive idle timeout.
+					a.lastActivity = time.Now()
 					a.handleBeaconMaybe(&result)
+					if a.handlePingMaybe(&result) {
+						continue
+					}
 					return &result, nil
 				} else {
 					return nil, ErrWrongChecksum
@@ -144,6 +233,12 @@ func (a *Adapter) Wait(command Command, direction Direction, timeout time.Durati
 		if message != nil && message.Command == command && message.Direction == direction {
 			return message, nil
 		}
+		if message == nil {
+			// Nothing usable yet, whether because there's no data
+			// (ErrNoData) or the keepalive idle timeout tripped
+			// (ErrIdleTimeout); avoid spinning the CPU either way.
+			time.Sleep(time.Millisecond)
+		}
 	}
 	return nil, ErrTimeout
 }
@@ -152,6 +247,9 @@ func (a *Adapter) Wait(command Command, direction Direction, timeout time.Durati
 func (a *Adapter) Reset() {
 	a.state = stateIdle
 	a.message = Message{}
+	a.frame = nil
+	a.trailer = nil
+	a.frameChecksum = nil
 	buf := make([]byte, 16)
 	for {
 		n, err := a.wire.Read(buf)
@@ -163,11 +261,14 @@ func (a *Adapter) Reset() {
 
 // BeaconTime returns the next time when a beacon with the given ID should be broadcasted.
 func (a *Adapter) BeaconTime(id byte) time.Time {
-	if a.beaconReferenceTime == 0 {
+	a.beaconsMu.RLock()
+	referenceTime := a.beaconReferenceTime
+	a.beaconsMu.RUnlock()
+	if referenceTime == 0 {
 		return time.Time{}
 	}
 	offset := beaconOffset(id)
-	t := a.beaconReferenceTime + offset.Milliseconds()
+	t := referenceTime + offset.Milliseconds()
 	now := time.Now().UnixMilli()
 	for t < now {
 		t += BeaconInterval.Milliseconds()
@@ -180,6 +281,10 @@ func (a *Adapter) handleBeaconMaybe(message *Message) {
 		return
 	}
 	id := message.Payload[0]
+	a.recordBeaconSeen(id)
+
+	a.beaconsMu.Lock()
+	defer a.beaconsMu.Unlock()
 	if a.lowestID == 0 || a.lowestID > id {
 		a.lowestID = id
 	}
@@ -0,0 +1,63 @@
+package csp
+
+import "testing"
+
+func TestChecksumImplementations(t *testing.T) {
+	frame := []byte{'$', 'C', 0x01, ChecksumXOR, 3, 0x10, 1, 2, 3}
+
+	for _, c := range []Checksum{xorChecksum{}, crc8Checksum{}, xxHashChecksum{}} {
+		sum := c.Compute(frame)
+		if len(sum) != c.Size() {
+			t.Fatalf("%T: Compute returned %d bytes, Size() says %d", c, len(sum), c.Size())
+		}
+		if !c.Verify(frame, sum) {
+			t.Fatalf("%T: Verify rejected its own Compute output", c)
+		}
+
+		corrupted := append([]byte{}, frame...)
+		corrupted[len(corrupted)-1] ^= 0xFF
+		if c.Verify(corrupted, sum) {
+			t.Fatalf("%T: Verify accepted a corrupted frame", c)
+		}
+	}
+}
+
+func TestChecksumByIDRoundTrip(t *testing.T) {
+	for _, id := range []byte{ChecksumXOR, ChecksumCRC8, ChecksumXXHash} {
+		c := checksumByID(id)
+		if got := checksumID(c); got != id {
+			t.Fatalf("checksumID(checksumByID(%#x)) = %#x, want %#x", id, got, id)
+		}
+	}
+}
+
+func TestChecksumByIDRejectsUnregisteredAlgoByte(t *testing.T) {
+	if c := checksumByID(0x7F); c != nil {
+		t.Fatalf("checksumByID(0x7F) = %#v, want nil for an unregistered algo byte", c)
+	}
+}
+
+// customChecksum stands in for a caller-supplied Checksum passed to
+// WithChecksum, as opposed to one of the three built-in algorithms.
+type customChecksum struct{}
+
+func (customChecksum) Size() int {
+	return 1
+}
+
+func (customChecksum) Compute(frame []byte) []byte {
+	return []byte{0}
+}
+
+func (customChecksum) Verify(frame []byte, sum []byte) bool {
+	return true
+}
+
+func TestChecksumIDPanicsOnUnregisteredChecksumType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("checksumID did not panic for a Checksum type outside the built-in three")
+		}
+	}()
+	checksumID(customChecksum{})
+}
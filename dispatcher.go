@@ -0,0 +1,82 @@
+package csp
+
+import (
+	"context"
+	"time"
+)
+
+// subscription pairs a filter (command, direction) with either a channel
+// or a callback to deliver matching messages to.
+type subscription struct {
+	command   Command
+	direction Direction
+	ch        chan<- *Message
+	fn        func(*Message)
+}
+
+// Start runs the state machine in a background goroutine and dispatches
+// every parsed message to subscribers registered via Subscribe or
+// SubscribeFunc. It replaces the caller-managed busy loop that a
+// reactive consumer would otherwise have to run around Receive/Wait.
+// Start returns immediately; the goroutine exits once ctx is done.
+func (a *Adapter) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			message, _ := a.Receive()
+			if message == nil {
+				// Nothing to parse yet; avoid spinning the CPU until more
+				// bytes show up on the wire.
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			a.dispatch(message)
+		}
+	}()
+}
+
+// Subscribe registers ch to receive every future message matching
+// command and direction. Delivery is non-blocking: if ch has no room,
+// the message is dropped rather than stalling dispatch to everyone else.
+func (a *Adapter) Subscribe(command Command, direction Direction, ch chan<- *Message) {
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	a.subs = append(a.subs, subscription{command: command, direction: direction, ch: ch})
+}
+
+// SubscribeFunc is Subscribe for callers who'd rather receive a callback
+// than manage a channel themselves.
+func (a *Adapter) SubscribeFunc(command Command, direction Direction, fn func(*Message)) {
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	a.subs = append(a.subs, subscription{command: command, direction: direction, fn: fn})
+}
+
+func (a *Adapter) dispatch(message *Message) {
+	a.subsMu.RLock()
+	matched := make([]subscription, 0, len(a.subs))
+	for _, s := range a.subs {
+		if s.command == message.Command && s.direction == message.Direction {
+			matched = append(matched, s)
+		}
+	}
+	a.subsMu.RUnlock()
+
+	for _, s := range matched {
+		if s.fn != nil {
+			// Run each callback in its own goroutine: a slow or blocking
+			// fn must not stall delivery to every other subscriber, nor
+			// to Start's whole dispatch goroutine.
+			go s.fn(message)
+			continue
+		}
+		select {
+		case s.ch <- message:
+		default:
+		}
+	}
+}
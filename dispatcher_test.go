@@ -0,0 +1,102 @@
+package csp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartDoesNotBusyLoopOnIdleWire(t *testing.T) {
+	wire := &countingReadWriter{}
+	a := NewAdapter(wire)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	reads := atomic.LoadInt64(&wire.reads)
+	// Start's goroutine calls Receive in a plain for loop; without a
+	// sleep on the no-data path it would poll Read as fast as the
+	// scheduler allows, so a low bound here catches a reintroduced spin.
+	if reads > 200 {
+		t.Fatalf("Start's dispatch goroutine polled Read %d times on an idle wire in 50ms; idle sleep appears missing", reads)
+	}
+}
+
+func TestDispatchDeliversOnlyToMatchingChannelSubscriber(t *testing.T) {
+	a := NewAdapter(&countingReadWriter{})
+
+	match := make(chan *Message, 1)
+	mismatchCmd := make(chan *Message, 1)
+	mismatchDir := make(chan *Message, 1)
+	a.Subscribe(Command(1), DirRequest, match)
+	a.Subscribe(Command(2), DirRequest, mismatchCmd)
+	a.Subscribe(Command(1), DirResponse, mismatchDir)
+
+	a.dispatch(&Message{Command: Command(1), Direction: DirRequest})
+
+	select {
+	case <-match:
+	default:
+		t.Fatal("matching subscriber never received the message")
+	}
+	select {
+	case <-mismatchCmd:
+		t.Fatal("subscriber for a different command received the message")
+	default:
+	}
+	select {
+	case <-mismatchDir:
+		t.Fatal("subscriber for a different direction received the message")
+	default:
+	}
+}
+
+func TestDispatchDeliversToMatchingFuncSubscriber(t *testing.T) {
+	a := NewAdapter(&countingReadWriter{})
+
+	received := make(chan *Message, 1)
+	a.SubscribeFunc(Command(1), DirRequest, func(m *Message) { received <- m })
+	a.SubscribeFunc(Command(2), DirRequest, func(m *Message) {
+		t.Error("func subscribed to a different command was invoked")
+	})
+
+	want := &Message{Command: Command(1), Direction: DirRequest}
+	a.dispatch(want)
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Fatalf("fn received %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("matching SubscribeFunc was never invoked")
+	}
+}
+
+func TestDispatchSlowFuncSubscriberDoesNotStallOthers(t *testing.T) {
+	a := NewAdapter(&countingReadWriter{})
+
+	var started sync.WaitGroup
+	started.Add(1)
+	release := make(chan struct{})
+	a.SubscribeFunc(Command(1), DirRequest, func(*Message) {
+		started.Done()
+		<-release // never closed during the test; simulates a stuck callback
+	})
+
+	ch := make(chan *Message, 1)
+	a.Subscribe(Command(1), DirRequest, ch)
+
+	a.dispatch(&Message{Command: Command(1), Direction: DirRequest})
+	started.Wait() // the blocking fn is now stuck inline in its own goroutine
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("channel subscriber was never delivered to; a blocked SubscribeFunc callback appears to have stalled dispatch")
+	}
+}
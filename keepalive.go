@@ -0,0 +1,66 @@
+package csp
+
+import "time"
+
+// CmdPing and CmdPong are zero-payload frames used by the keepalive
+// mechanism to detect a dead peer between beacons. They are handled
+// internally by Receive and never surfaced to callers.
+const (
+	CmdPing Command = 0xF0
+	CmdPong Command = 0xF1
+)
+
+// SetKeepalive enables the idle-timeout watchdog: if no valid frame is
+// received within idle, Receive returns ErrIdleTimeout. While blocked on
+// Receive/Wait with no traffic, a ping frame is sent automatically every
+// ping interval to prod the peer into responding. Pass zero durations to
+// disable the mechanism.
+func (a *Adapter) SetKeepalive(ping, idle time.Duration) {
+	a.pingInterval = ping
+	a.idleTimeout = idle
+	a.lastActivity = time.Now()
+	a.lastPingSent = time.Time{}
+}
+
+// checkKeepalive is invoked whenever Receive finds no bytes to read. It
+// sends a ping if due and reports whether the idle timeout has elapsed.
+func (a *Adapter) checkKeepalive() (bool, error) {
+	if a.idleTimeout == 0 && a.pingInterval == 0 {
+		return false, nil
+	}
+	now := time.Now()
+	if a.idleTimeout > 0 && now.Sub(a.lastActivity) >= a.idleTimeout {
+		return true, ErrIdleTimeout
+	}
+	if a.pingInterval > 0 && now.Sub(a.lastActivity) >= a.pingInterval && now.Sub(a.lastPingSent) >= a.pingInterval {
+		a.lastPingSent = now
+		_ = a.sendPing()
+	}
+	return false, nil
+}
+
+// handlePingMaybe answers CmdPing with a CmdPong and swallows both, so
+// neither is ever returned to the caller of Receive.
+func (a *Adapter) handlePingMaybe(message *Message) bool {
+	switch message.Command {
+	case CmdPing:
+		_ = a.Send(&Message{
+			Direction: DirResponse,
+			Command:   CmdPong,
+			Payload:   []byte{},
+		})
+		return true
+	case CmdPong:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *Adapter) sendPing() error {
+	return a.Send(&Message{
+		Direction: DirRequest,
+		Command:   CmdPing,
+		Payload:   []byte{},
+	})
+}
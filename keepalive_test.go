@@ -0,0 +1,89 @@
+package csp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// blockedReadWriter is an io.ReadWriter that never has data available,
+// simulating a peer that has gone silent.
+type blockedReadWriter struct{}
+
+func (blockedReadWriter) Read(p []byte) (int, error)  { return 0, nil }
+func (blockedReadWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestReceiveReturnsErrIdleTimeoutAfterSilence(t *testing.T) {
+	a := NewAdapter(blockedReadWriter{})
+	a.SetKeepalive(0, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		_, err := a.Receive()
+		if err == ErrIdleTimeout {
+			return
+		}
+	}
+	t.Fatal("Receive never returned ErrIdleTimeout on a silent wire")
+}
+
+// noiseReadWriter keeps the wire busy with bytes that never resolve to a
+// valid frame ('$' never appears), the way line noise from a failing
+// serial link would.
+type noiseReadWriter struct{}
+
+func (noiseReadWriter) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0xFF
+	}
+	return len(p), nil
+}
+
+func (noiseReadWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestReceiveReturnsErrIdleTimeoutOnLineNoise(t *testing.T) {
+	a := NewAdapter(noiseReadWriter{})
+	a.SetKeepalive(0, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		_, err := a.Receive()
+		if err == ErrIdleTimeout {
+			return
+		}
+	}
+	t.Fatal("Receive never returned ErrIdleTimeout while the wire kept delivering bytes that never formed a valid frame")
+}
+
+func TestReceiveKeepsActivityAliveOnValidFrames(t *testing.T) {
+	aConn, bConn := net.Pipe()
+	defer aConn.Close()
+	defer bConn.Close()
+
+	a := NewAdapter(aConn)
+	a.SetKeepalive(0, 50*time.Millisecond)
+	b := NewAdapter(bConn)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ping := time.NewTicker(10 * time.Millisecond)
+		defer ping.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ping.C:
+				_ = b.Send(&Message{Direction: DirRequest, Command: Command(1), Payload: []byte{}})
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		_, err := a.Receive()
+		if err == ErrIdleTimeout {
+			t.Fatal("Receive returned ErrIdleTimeout despite a steady stream of valid frames")
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package csp
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrMessageTooLarge = errors.New("message exceeds channel's max message size")
+
+// ChannelDescriptor configures one logical channel multiplexed over a
+// single Adapter. Higher Priority channels get a larger share of the
+// weighted round-robin send loop.
+type ChannelDescriptor struct {
+	ID                byte
+	Priority          int
+	SendQueueCapacity int
+	MaxMessageSize    int
+
+	// DropOldest, when true, makes a full send queue evict its oldest
+	// pending message instead of blocking Channel.Send. When false (the
+	// default), Send blocks until space frees up. Either way, delivery
+	// into the channel's inbox from receiveLoop never blocks: a slow
+	// consumer on one channel must not stall demuxing for every other
+	// channel sharing the wire. With DropOldest set, a full inbox evicts
+	// its oldest message instead of dropping the new one.
+	DropOldest bool
+}
+
+// Channel is one logical stream multiplexed over the wire shared by all
+// channels registered on a Mux.
+type Channel struct {
+	desc ChannelDescriptor
+
+	outbox chan *Message
+	inbox  chan *Message
+}
+
+// Send queues message for transmission on this channel. It blocks if the
+// send queue is full, unless the channel descriptor sets DropOldest.
+func (c *Channel) Send(message *Message) error {
+	if len(message.Payload) > c.desc.MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+	// +1 for the channel-ID byte Send prepends below; without this the
+	// framed payload could exceed maxPayload and fail stateLength's bound
+	// in Receive on the other end.
+	if len(message.Payload)+1 > maxPayload {
+		return ErrMessageTooLarge
+	}
+	framed := *message
+	framed.Payload = append([]byte{c.desc.ID}, message.Payload...)
+
+	if !c.desc.DropOldest {
+		c.outbox <- &framed
+		return nil
+	}
+	select {
+	case c.outbox <- &framed:
+	default:
+		select {
+		case <-c.outbox:
+		default:
+		}
+		c.outbox <- &framed
+	}
+	return nil
+}
+
+// Receive returns the next message demultiplexed onto this channel, or
+// nil if none is available yet.
+func (c *Channel) Receive() *Message {
+	select {
+	case message := <-c.inbox:
+		return message
+	default:
+		return nil
+	}
+}
+
+// Mux multiplexes logical channels over a single Adapter so a high-rate
+// channel (e.g. telemetry) cannot starve a low-rate one (e.g. config) on
+// the same UART.
+type Mux struct {
+	adapter *Adapter
+
+	mu       sync.Mutex
+	channels map[byte]*Channel
+
+	stop chan struct{}
+}
+
+// NewMux wraps adapter and starts the background goroutines that drain
+// channel outboxes to the wire and demultiplex inbound frames.
+func NewMux(adapter *Adapter) *Mux {
+	m := &Mux{
+		adapter:  adapter,
+		channels: map[byte]*Channel{},
+		stop:     make(chan struct{}),
+	}
+	go m.sendLoop()
+	go m.receiveLoop()
+	return m
+}
+
+// AddChannel registers a new logical channel and returns a handle used to
+// send and receive on it.
+func (m *Mux) AddChannel(desc ChannelDescriptor) *Channel {
+	// Leave room for the channel-ID byte Send prepends to every message.
+	if desc.MaxMessageSize > maxPayload-1 {
+		desc.MaxMessageSize = maxPayload - 1
+	}
+	// A zero (or negative) Priority would make sendLoop's weighted
+	// round-robin loop a permanent no-op for this channel, starving it
+	// completely rather than merely de-prioritizing it.
+	if desc.Priority < 1 {
+		desc.Priority = 1
+	}
+	c := &Channel{
+		desc:   desc,
+		outbox: make(chan *Message, desc.SendQueueCapacity),
+		inbox:  make(chan *Message, desc.SendQueueCapacity),
+	}
+	m.mu.Lock()
+	m.channels[desc.ID] = c
+	m.mu.Unlock()
+	return c
+}
+
+// Close stops the Mux's background goroutines. The underlying Adapter is
+// left open for the caller to close.
+func (m *Mux) Close() {
+	close(m.stop)
+}
+
+// sendLoop drains each channel's outbox in weighted round-robin order,
+// giving higher-Priority channels proportionally more turns per pass.
+func (m *Mux) sendLoop() {
+	for {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+		sentAny := false
+		for _, c := range m.orderedChannels() {
+			for i := 0; i < c.desc.Priority; i++ {
+				select {
+				case message := <-c.outbox:
+					_ = m.adapter.Send(message)
+					sentAny = true
+				default:
+				}
+			}
+		}
+		if !sentAny {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// receiveLoop reads frames off the wire and routes each into the inbox of
+// the channel named by the message's leading payload byte.
+func (m *Mux) receiveLoop() {
+	for {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+		message, _ := m.adapter.Receive()
+		if message == nil {
+			// Nothing to parse yet; avoid spinning the CPU until more
+			// bytes show up on the wire.
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if message.Command == CmdBeacon || len(message.Payload) == 0 {
+			// Beacons (and anything else not destined for a channel
+			// payload) aren't swallowed upstream the way Ping/Pong are;
+			// a beacon's node-ID byte must not be mistaken for a channel
+			// ID here.
+			continue
+		}
+
+		id := message.Payload[0]
+		m.mu.Lock()
+		c, ok := m.channels[id]
+		m.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		demuxed := *message
+		demuxed.Payload = message.Payload[1:]
+
+		if !c.desc.DropOldest {
+			// A full inbox drops the new message rather than blocking:
+			// receiveLoop is shared by every channel, and stalling it on
+			// one slow consumer would starve demuxing for all the others.
+			select {
+			case c.inbox <- &demuxed:
+			default:
+			}
+			continue
+		}
+		select {
+		case c.inbox <- &demuxed:
+		default:
+			select {
+			case <-c.inbox:
+			default:
+			}
+			c.inbox <- &demuxed
+		}
+	}
+}
+
+func (m *Mux) orderedChannels() []*Channel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*Channel, 0, len(m.channels))
+	for _, c := range m.channels {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].desc.ID < out[j].desc.ID })
+	return out
+}
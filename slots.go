@@ -0,0 +1,94 @@
+package csp
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSlotMissed is returned by SendInSlot when no reference beacon has
+// been observed yet, or when this node's slot closes before the write can
+// happen. SendInSlot takes no deadline: it waits for the slot however far
+// off its next opening is.
+var ErrSlotMissed = errors.New("missed this node's TDMA slot")
+
+// slotDuration is the width of one pilot's transmit slot within the
+// BeaconInterval cycle (16 team×player slots of 6s/... fit the existing
+// beaconOffset scheme with room to spare).
+const slotDuration = 100 * time.Millisecond
+
+// WithID sets this adapter's own node ID, used by SendInSlot and SlotFor
+// to compute which TDMA slot belongs to this node.
+func WithID(id byte) Option {
+	return func(a *Adapter) {
+		a.id = id
+	}
+}
+
+// SlotFor returns the start and end of id's 100ms transmit slot within
+// the current beacon cycle, anchored to the reference beacon time that
+// handleBeaconMaybe maintains from observed CmdBeacon traffic. It returns
+// the zero Time pair until a reference beacon has been observed.
+func (a *Adapter) SlotFor(id byte) (time.Time, time.Time) {
+	a.beaconsMu.RLock()
+	referenceTime := a.beaconReferenceTime
+	a.beaconsMu.RUnlock()
+	if referenceTime == 0 {
+		return time.Time{}, time.Time{}
+	}
+	offset := beaconOffset(id)
+	start := referenceTime + offset.Milliseconds()
+	now := time.Now().UnixMilli()
+	for start+slotDuration.Milliseconds() < now {
+		start += BeaconInterval.Milliseconds()
+	}
+	end := start + slotDuration.Milliseconds()
+	return time.UnixMilli(start), time.UnixMilli(end)
+}
+
+// SendInSlot blocks until this adapter's own TDMA slot (see SlotFor)
+// opens, then sends message. It returns ErrSlotMissed rather than
+// transmitting outside the slot and colliding with another pilot.
+func (a *Adapter) SendInSlot(message *Message) error {
+	start, end := a.SlotFor(a.id)
+	if start.IsZero() {
+		return ErrSlotMissed
+	}
+	now := time.Now()
+	if !now.Before(end) {
+		return ErrSlotMissed
+	}
+	if now.Before(start) {
+		timer := time.NewTimer(start.Sub(now))
+		defer timer.Stop()
+		<-timer.C
+	}
+	if !time.Now().Before(end) {
+		return ErrSlotMissed
+	}
+	return a.Send(message)
+}
+
+// SlotOccupancy reports, for every node ID whose beacon has ever been
+// observed, whether its slot is currently occupied: true if its latest
+// beacon arrived within the last BeaconInterval, false otherwise. IDs
+// never heard from are absent from the map. A pilot can consult this before joining to pick a free one of the 16
+// team×player slots.
+func (a *Adapter) SlotOccupancy() map[byte]bool {
+	a.beaconsMu.RLock()
+	defer a.beaconsMu.RUnlock()
+	cutoff := time.Now().Add(-BeaconInterval)
+	occupancy := make(map[byte]bool, len(a.beaconsSeen))
+	for id, seen := range a.beaconsSeen {
+		occupancy[id] = seen.After(cutoff)
+	}
+	return occupancy
+}
+
+func (a *Adapter) recordBeaconSeen(id byte) {
+	a.beaconsMu.Lock()
+	defer a.beaconsMu.Unlock()
+	if a.beaconsSeen == nil {
+		a.beaconsSeen = map[byte]time.Time{}
+	}
+	a.beaconsSeen[id] = time.Now()
+}
@@ -0,0 +1,208 @@
+// Package transport provides io.ReadWriter implementations that can back
+// a csp.Adapter besides a plain serial port.
+package transport
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/ysoldak/fpvc-serial-protocol"
+)
+
+// envelopeSize is the (senderID, seq) header Broadcast prepends to every
+// frame so it can recognize and drop its own broadcasts echoed back by
+// the network.
+const envelopeSize = 8
+
+// maxDatagramSize comfortably covers the largest CSP frame ($, C, dir,
+// algo, len, cmd, up to ~112 bytes of payload, trailer) plus envelope.
+// UDP is datagram-oriented: ReadFromUDP delivers (and discards the rest
+// of) exactly one datagram per call, so the read buffer must be sized
+// for the biggest frame up front rather than whatever scratch buffer the
+// Adapter's internal read loop happens to pass in.
+const maxDatagramSize = 512
+
+type dedupKey struct {
+	senderID uint32
+	seq      uint32
+}
+
+// dedupWindowPerSender bounds how many recent sequence numbers are
+// remembered per sender. Eviction is FIFO (oldest seq out first) so a
+// burst from one sender can't wipe another's recently-seen entries, and
+// a sender's own most recent broadcasts stay recognized as it echoes
+// back on the LAN.
+const dedupWindowPerSender = 64
+
+// senderWindow is the sliding set of sequence numbers recently seen from
+// one sender.
+type senderWindow struct {
+	seen  map[uint32]struct{}
+	order []uint32
+}
+
+// Broadcast is an io.ReadWriter backed by UDP broadcast: it binds a UDP
+// port, listens for inbound frames, and on Write enumerates local
+// interface addresses to send the frame to each one's broadcast address.
+// This lets several ground stations on the same LAN exchange CSP frames
+// without serial hardware.
+type Broadcast struct {
+	conn   *net.UDPConn
+	port   int
+	selfID uint32
+	seq    uint32
+
+	mu   sync.Mutex
+	seen map[uint32]*senderWindow
+
+	// pending holds payload bytes from the most recently read datagram
+	// that didn't fit in the caller's buffer, to be handed back on the
+	// next Read call (same contract as a stream, despite the transport).
+	pending []byte
+}
+
+// NewBroadcast binds a UDP socket on port and returns a Broadcast ready
+// to read and write CSP frames over it.
+func NewBroadcast(port int) (*Broadcast, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: port})
+	if err != nil {
+		return nil, err
+	}
+	return &Broadcast{
+		conn:   conn,
+		port:   port,
+		selfID: rand.Uint32(),
+		seen:   map[uint32]*senderWindow{},
+	}, nil
+}
+
+// Read blocks for the next inbound frame that isn't one of ours echoed
+// back by the broadcast, strips the dedup envelope, and copies the
+// payload into p. If a whole datagram didn't fit in p, the remainder is
+// buffered and returned on the next call, since Adapter reads in small
+// fixed-size chunks but a UDP datagram can't be read out piecemeal.
+func (b *Broadcast) Read(p []byte) (int, error) {
+	if len(b.pending) > 0 {
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		return n, nil
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, _, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n < envelopeSize {
+			continue
+		}
+		key := dedupKey{
+			senderID: binary.BigEndian.Uint32(buf[0:4]),
+			seq:      binary.BigEndian.Uint32(buf[4:8]),
+		}
+		if b.isDuplicate(key) {
+			continue
+		}
+		payload := buf[envelopeSize:n]
+		copied := copy(p, payload)
+		if copied < len(payload) {
+			b.pending = append([]byte{}, payload[copied:]...)
+		}
+		return copied, nil
+	}
+}
+
+// Write broadcasts p to the broadcast address of every local interface.
+func (b *Broadcast) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	b.seq++
+	key := dedupKey{senderID: b.selfID, seq: b.seq}
+	b.markSeen(key.senderID, key.seq)
+	b.mu.Unlock()
+
+	frame := make([]byte, envelopeSize+len(p))
+	binary.BigEndian.PutUint32(frame[0:4], key.senderID)
+	binary.BigEndian.PutUint32(frame[4:8], key.seq)
+	copy(frame[envelopeSize:], p)
+
+	for _, addr := range broadcastAddresses(b.port) {
+		_, _ = b.conn.WriteToUDP(frame, addr)
+	}
+	return len(p), nil
+}
+
+// Close releases the underlying UDP socket.
+func (b *Broadcast) Close() error {
+	return b.conn.Close()
+}
+
+func (b *Broadcast) isDuplicate(key dedupKey) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w, ok := b.seen[key.senderID]; ok {
+		if _, dup := w.seen[key.seq]; dup {
+			return true
+		}
+	}
+	b.markSeen(key.senderID, key.seq)
+	return false
+}
+
+// markSeen records (senderID, seq) as seen, evicting that sender's
+// oldest remembered seq once its window is full. Callers hold b.mu.
+func (b *Broadcast) markSeen(senderID, seq uint32) {
+	w, ok := b.seen[senderID]
+	if !ok {
+		w = &senderWindow{seen: map[uint32]struct{}{}}
+		b.seen[senderID] = w
+	}
+	w.seen[seq] = struct{}{}
+	w.order = append(w.order, seq)
+	if len(w.order) > dedupWindowPerSender {
+		oldest := w.order[0]
+		w.order = w.order[1:]
+		delete(w.seen, oldest)
+	}
+}
+
+func broadcastAddresses(port int) []*net.UDPAddr {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var addrs []*net.UDPAddr
+	for _, iface := range ifaces {
+		ifAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range ifAddrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			mask := ipNet.Mask
+			broadcast := make(net.IP, len(ip))
+			for i := range broadcast {
+				broadcast[i] = ip[i] | ^mask[i]
+			}
+			addrs = append(addrs, &net.UDPAddr{IP: broadcast, Port: port})
+		}
+	}
+	return addrs
+}
+
+// NewBroadcastAdapter binds a Broadcast on port and wires it into a new
+// csp.Adapter unchanged, so ground stations can exchange CSP messages
+// (including CmdBeacon) over a LAN instead of a dedicated serial link.
+func NewBroadcastAdapter(port int, opts ...csp.Option) (*csp.Adapter, error) {
+	b, err := NewBroadcast(port)
+	if err != nil {
+		return nil, err
+	}
+	return csp.NewAdapter(b, opts...), nil
+}
@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestBroadcastReadReassemblesDatagramAcrossSmallReads(t *testing.T) {
+	b, err := NewBroadcast(0)
+	if err != nil {
+		t.Fatalf("NewBroadcast: %v", err)
+	}
+	defer b.Close()
+
+	sender, err := net.DialUDP("udp4", nil, b.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("DialUDP: %v", err)
+	}
+	defer sender.Close()
+
+	payload := []byte("a CSP frame much longer than Adapter's 16-byte scratch buffer")
+	datagram := make([]byte, envelopeSize+len(payload))
+	binary.BigEndian.PutUint32(datagram[0:4], 42)
+	binary.BigEndian.PutUint32(datagram[4:8], 1)
+	copy(datagram[envelopeSize:], payload)
+
+	if _, err := sender.Write(datagram); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Adapter reads in small fixed-size chunks; Read must hand back the
+	// whole datagram across repeated calls instead of truncating it to
+	// whatever small buffer happens to be passed in.
+	small := make([]byte, 16)
+	got := make([]byte, 0, len(payload))
+	for len(got) < len(payload) {
+		n, err := b.Read(small)
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		got = append(got, small[:n]...)
+	}
+
+	if string(got) != string(payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+func TestBroadcastDropsDuplicateEnvelope(t *testing.T) {
+	b, err := NewBroadcast(0)
+	if err != nil {
+		t.Fatalf("NewBroadcast: %v", err)
+	}
+	defer b.Close()
+
+	key := dedupKey{senderID: 7, seq: 3}
+	if b.isDuplicate(key) {
+		t.Fatal("first sighting of (senderID, seq) reported as duplicate")
+	}
+	if !b.isDuplicate(key) {
+		t.Fatal("second sighting of the same (senderID, seq) was not suppressed")
+	}
+}
+
+// TestBroadcastDedupSurvivesUnrelatedFlood guards against the old
+// behavior where exceeding a single global table size wiped every
+// sender's recent history at once, including a sender's own very recent
+// broadcasts still echoing back on the LAN. A flood from one sender must
+// only evict that sender's oldest entries.
+func TestBroadcastDedupSurvivesUnrelatedFlood(t *testing.T) {
+	b, err := NewBroadcast(0)
+	if err != nil {
+		t.Fatalf("NewBroadcast: %v", err)
+	}
+	defer b.Close()
+
+	recent := dedupKey{senderID: 1, seq: 1}
+	if b.isDuplicate(recent) {
+		t.Fatal("first sighting reported as duplicate")
+	}
+
+	flood := dedupKey{senderID: 2}
+	for i := uint32(0); i < dedupWindowPerSender*4; i++ {
+		flood.seq = i
+		b.isDuplicate(flood)
+	}
+
+	if !b.isDuplicate(recent) {
+		t.Fatal("an unrelated sender's flood evicted a different sender's recent entry")
+	}
+}
@@ -0,0 +1,80 @@
+package csp
+
+import "encoding/binary"
+
+// xxhash64 is a minimal implementation of the xxHash64 algorithm, kept
+// in-tree so the checksum layer has no external dependency.
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxhash64(input []byte, seed uint64) uint64 {
+	n := len(input)
+	i := 0
+	var h64 uint64
+
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; i+32 <= n; i += 32 {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(input[i:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(input[i+8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(input[i+16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(input[i+24:]))
+		}
+		h64 = xxRotl(v1, 1) + xxRotl(v2, 7) + xxRotl(v3, 12) + xxRotl(v4, 18)
+		h64 = xxMergeRound(h64, v1)
+		h64 = xxMergeRound(h64, v2)
+		h64 = xxMergeRound(h64, v3)
+		h64 = xxMergeRound(h64, v4)
+	} else {
+		h64 = seed + xxPrime5
+	}
+
+	h64 += uint64(n)
+
+	for ; i+8 <= n; i += 8 {
+		k1 := xxRound(0, binary.LittleEndian.Uint64(input[i:]))
+		h64 ^= k1
+		h64 = xxRotl(h64, 27)*xxPrime1 + xxPrime4
+	}
+	if i+4 <= n {
+		h64 ^= uint64(binary.LittleEndian.Uint32(input[i:])) * xxPrime1
+		h64 = xxRotl(h64, 23)*xxPrime2 + xxPrime3
+		i += 4
+	}
+	for ; i < n; i++ {
+		h64 ^= uint64(input[i]) * xxPrime5
+		h64 = xxRotl(h64, 11) * xxPrime1
+	}
+
+	h64 ^= h64 >> 33
+	h64 *= xxPrime2
+	h64 ^= h64 >> 29
+	h64 *= xxPrime3
+	h64 ^= h64 >> 32
+
+	return h64
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = xxRotl(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	val = xxRound(0, val)
+	acc ^= val
+	return acc*xxPrime1 + xxPrime4
+}
+
+func xxRotl(x uint64, r uint) uint64 {
+	return (x << r) | (x >> (64 - r))
+}